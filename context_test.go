@@ -0,0 +1,72 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireContext_SucceedsWhenPermitAvailable(t *testing.T) {
+	s := New(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.AcquireContext(ctx); err != nil {
+		t.Fatalf("AcquireContext: %v", err)
+	}
+	if got := s.AvailablePermits(); got != 0 {
+		t.Fatalf("AvailablePermits() = %d, want 0", got)
+	}
+}
+
+func TestAcquireManyContext_TimesOutWhenPermitsUnavailable(t *testing.T) {
+	s := New(1)
+	s.Acquire()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.AcquireManyContext(ctx, 1)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("AcquireManyContext: got %v, want context.DeadlineExceeded", err)
+	}
+	// The timed-out waiter must not have been left holding a permit.
+	if got := s.AvailablePermits(); got != 0 {
+		t.Fatalf("AvailablePermits() = %d, want 0", got)
+	}
+}
+
+func TestAcquireContext_ReturnsContextErrOnCancel(t *testing.T) {
+	s := New(1)
+	s.Acquire()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.AcquireContext(ctx); err != context.Canceled {
+		t.Fatalf("AcquireContext: got %v, want context.Canceled", err)
+	}
+}
+
+func TestAcquireWithin_TrueWhenPermitAvailable(t *testing.T) {
+	s := New(1)
+	if !s.AcquireWithin(1, time.Second) {
+		t.Fatal("AcquireWithin: expected true, permit was available")
+	}
+}
+
+func TestAcquireWithin_FalseOnTimeout(t *testing.T) {
+	s := New(1)
+	s.Acquire()
+
+	start := time.Now()
+	if s.AcquireWithin(1, 20*time.Millisecond) {
+		t.Fatal("AcquireWithin: expected false, no permits were available")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("AcquireWithin took %v to time out after a 20ms duration", elapsed)
+	}
+	// A false AcquireWithin must not leave a permit granted to the caller.
+	if got := s.AvailablePermits(); got != 0 {
+		t.Fatalf("AvailablePermits() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,202 @@
+package semaphore
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HolderInfo describes one in-flight named acquisition, as reported by
+// Holders().
+type HolderInfo struct {
+	ID         int
+	N          int
+	AcquiredAt time.Time
+	Stack      string
+}
+
+// debugState holds the optional holder-tracking/leak-detection bookkeeping
+// for a Semaphore. It is only allocated once EnableDebug is called, so
+// semaphores that don't use it pay no extra cost.
+type debugState struct {
+	mutex        sync.Mutex
+	captureStack bool
+	holders      map[int64]HolderInfo // keyed by acquisition token, not caller id
+
+	leakTimeout  time.Duration
+	leakCallback func(HolderInfo)
+	reported     map[int64]bool // tokens already passed to leakCallback, so it fires once per holder
+	reaperCancel context.CancelFunc
+}
+
+// EnableDebug turns this Semaphore into a diagnosable resource governor: it
+// starts tracking every acquisition made through AcquireNamed /
+// AcquireNamedContext so it can be inspected with Holders() and, optionally,
+// SetLeakTimeout. When captureStack is true, each acquisition also records
+// the caller's stack via runtime.Stack, which is considerably more expensive
+// and should typically be reserved for debugging a specific leak.
+func (s *Semaphore) EnableDebug(captureStack bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.debug == nil {
+		s.debug = &debugState{}
+	}
+	s.debug.captureStack = captureStack
+}
+
+// AcquireNamed is similar to AcquireMany but tags the acquisition with id so
+// it can be inspected via Holders() until the matching ReleaseNamed call. It
+// returns a token identifying this specific acquisition; pass it to
+// ReleaseNamed instead of id, since id itself need not be unique (e.g. two
+// overlapping acquisitions from the same named worker). The tag is only
+// recorded if EnableDebug has been called; otherwise AcquireNamed behaves
+// exactly like AcquireMany, minus the token being meaningful for lookup.
+func (s *Semaphore) AcquireNamed(id, n int) (token int64, err error) {
+	return s.AcquireNamedContext(context.Background(), id, n)
+}
+
+// AcquireNamedContext is similar to AcquireNamed but honours ctx cancellation,
+// as AcquireManyContext does.
+func (s *Semaphore) AcquireNamedContext(ctx context.Context, id, n int) (token int64, err error) {
+	if err := s.acquire(ctx, n); err != nil {
+		return 0, err
+	}
+	token = atomic.AddInt64(&s.nextToken, 1)
+	s.recordHolder(token, id, n)
+	return token, nil
+}
+
+// ReleaseNamed is ReleaseMany for an acquisition made with AcquireNamed,
+// identified by the token that call returned; it also removes that
+// acquisition from Holders().
+func (s *Semaphore) ReleaseNamed(token int64, n int) {
+	s.forgetHolder(token)
+	s.ReleaseMany(n)
+}
+
+// Holders returns a snapshot of every acquisition currently tracked via
+// AcquireNamed. It returns nil if EnableDebug was never called.
+func (s *Semaphore) Holders() []HolderInfo {
+	d := s.debugState()
+	if d == nil {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	holders := make([]HolderInfo, 0, len(d.holders))
+	for _, info := range d.holders {
+		holders = append(holders, info)
+	}
+	return holders
+}
+
+// debugState returns the Semaphore's debug bookkeeping, or nil if EnableDebug
+// has never been called.
+func (s *Semaphore) debugState() *debugState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.debug
+}
+
+func (s *Semaphore) recordHolder(token int64, id, n int) {
+	d := s.debugState()
+	if d == nil {
+		return
+	}
+
+	info := HolderInfo{ID: id, N: n, AcquiredAt: time.Now()}
+	if d.captureStack {
+		buf := make([]byte, 4096)
+		info.Stack = string(buf[:runtime.Stack(buf, false)])
+	}
+
+	d.mutex.Lock()
+	if d.holders == nil {
+		d.holders = make(map[int64]HolderInfo)
+	}
+	d.holders[token] = info
+	d.mutex.Unlock()
+}
+
+func (s *Semaphore) forgetHolder(token int64) {
+	d := s.debugState()
+	if d == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	delete(d.holders, token)
+	delete(d.reported, token)
+	d.mutex.Unlock()
+}
+
+// SetLeakTimeout arranges for cb to be called, from a background goroutine,
+// with the HolderInfo of any named acquisition that has been held for at
+// least d. EnableDebug must be called first. cb fires at most once per
+// holder for each SetLeakTimeout call; a later call clears that record, so a
+// holder already reported can be reported again. The reaper goroutine runs until ctx is done, so callers that no
+// longer need leak detection (or are discarding the Semaphore) should cancel
+// ctx to stop it; calling SetLeakTimeout again replaces the running reaper
+// with one tied to the new ctx.
+func (s *Semaphore) SetLeakTimeout(ctx context.Context, d time.Duration, cb func(HolderInfo)) {
+	state := s.debugState()
+	if state == nil {
+		panic("semaphore: SetLeakTimeout called without EnableDebug")
+	}
+
+	state.mutex.Lock()
+	state.leakTimeout = d
+	state.leakCallback = cb
+	state.reported = make(map[int64]bool)
+	if state.reaperCancel != nil {
+		state.reaperCancel()
+	}
+	reaperCtx, cancel := context.WithCancel(ctx)
+	state.reaperCancel = cancel
+	state.mutex.Unlock()
+
+	go reapLeaks(reaperCtx, state)
+}
+
+// reapLeaks periodically scans the tracked holders for any acquisition held
+// longer than the configured leak timeout and reports each one to the
+// callback once, until ctx is done.
+func reapLeaks(ctx context.Context, state *debugState) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		state.mutex.Lock()
+		timeout := state.leakTimeout
+		cb := state.leakCallback
+		var leaked []HolderInfo
+		if timeout > 0 && cb != nil {
+			now := time.Now()
+			for token, info := range state.holders {
+				if state.reported[token] {
+					continue
+				}
+				if now.Sub(info.AcquiredAt) >= timeout {
+					state.reported[token] = true
+					leaked = append(leaked, info)
+				}
+			}
+		}
+		state.mutex.Unlock()
+
+		for _, info := range leaked {
+			cb(info)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// SetLeakTimeout must tie its reaper goroutine to the given ctx instead of
+// running it for the lifetime of the process.
+func TestSetLeakTimeout_CancelStopsReaper(t *testing.T) {
+	s := New(1)
+	s.EnableDebug(false)
+
+	token, err := s.AcquireNamed(1, 1)
+	if err != nil {
+		t.Fatalf("AcquireNamed: %v", err)
+	}
+	defer s.ReleaseNamed(token, 1)
+
+	fired := make(chan HolderInfo, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.SetLeakTimeout(ctx, time.Millisecond, func(info HolderInfo) {
+		select {
+		case fired <- info:
+		default:
+		}
+	})
+
+	if s.debugState().reaperCancel == nil {
+		t.Fatal("expected SetLeakTimeout to record a reaperCancel")
+	}
+
+	// Canceling ctx must be all that's needed to stop the reaper. The held
+	// acquisition is already past its 1ms leak timeout, so a reaper still
+	// running at its first one-second tick would report it.
+	cancel()
+	select {
+	case <-fired:
+		t.Fatal("leak callback fired after ctx was canceled: the reaper is still running")
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
+
+// Replacing the leak timeout must install a new reaper and stop the previous
+// one through its internal derived ctx only: the caller's own ctx1 is left
+// untouched. (Both reapers read the same callback from the shared state, so
+// whether the old one still runs cannot be seen through the callback.)
+func TestSetLeakTimeout_ReplacesPreviousReaper(t *testing.T) {
+	s := New(1)
+	s.EnableDebug(false)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	s.SetLeakTimeout(ctx1, time.Millisecond, func(HolderInfo) {})
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	s.SetLeakTimeout(ctx2, time.Millisecond, func(HolderInfo) {})
+
+	if s.debugState().reaperCancel == nil {
+		t.Fatal("expected SetLeakTimeout to record a reaperCancel")
+	}
+	if ctx1.Err() != nil {
+		t.Fatal("SetLeakTimeout must not cancel the caller's own ctx1, only its internal derived reaper ctx")
+	}
+}
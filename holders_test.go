@@ -0,0 +1,83 @@
+package semaphore
+
+import (
+	"strings"
+	"testing"
+)
+
+// Two overlapping AcquireNamed calls sharing a caller id must not collide in
+// the holder map: both should show up in Holders(), and releasing one must
+// not forget the other.
+func TestAcquireNamed_SharedIDDoesNotCollide(t *testing.T) {
+	s := New(4)
+	s.EnableDebug(false)
+
+	tokenA, err := s.AcquireNamed(1, 1)
+	if err != nil {
+		t.Fatalf("AcquireNamed #1: %v", err)
+	}
+	tokenB, err := s.AcquireNamed(1, 1)
+	if err != nil {
+		t.Fatalf("AcquireNamed #2: %v", err)
+	}
+	if tokenA == tokenB {
+		t.Fatalf("expected distinct tokens, got %d twice", tokenA)
+	}
+
+	if got := len(s.Holders()); got != 2 {
+		t.Fatalf("Holders() length = %d, want 2", got)
+	}
+
+	s.ReleaseNamed(tokenA, 1)
+
+	holders := s.Holders()
+	if len(holders) != 1 {
+		t.Fatalf("Holders() length after one release = %d, want 1", len(holders))
+	}
+
+	s.ReleaseNamed(tokenB, 1)
+	if got := len(s.Holders()); got != 0 {
+		t.Fatalf("Holders() length after both released = %d, want 0", got)
+	}
+}
+
+func TestAcquireNamed_CaptureStackPopulatesHolderInfo(t *testing.T) {
+	s := New(1)
+	s.EnableDebug(true)
+
+	token, err := s.AcquireNamed(1, 1)
+	if err != nil {
+		t.Fatalf("AcquireNamed: %v", err)
+	}
+	defer s.ReleaseNamed(token, 1)
+
+	holders := s.Holders()
+	if len(holders) != 1 {
+		t.Fatalf("Holders() length = %d, want 1", len(holders))
+	}
+	if holders[0].Stack == "" {
+		t.Fatal("HolderInfo.Stack is empty, want a captured stack trace")
+	}
+	if !strings.Contains(holders[0].Stack, "TestAcquireNamed_CaptureStackPopulatesHolderInfo") {
+		t.Fatalf("HolderInfo.Stack = %q, want it to mention this test function", holders[0].Stack)
+	}
+}
+
+func TestAcquireNamed_NoCaptureStackLeavesHolderInfoEmpty(t *testing.T) {
+	s := New(1)
+	s.EnableDebug(false)
+
+	token, err := s.AcquireNamed(1, 1)
+	if err != nil {
+		t.Fatalf("AcquireNamed: %v", err)
+	}
+	defer s.ReleaseNamed(token, 1)
+
+	holders := s.Holders()
+	if len(holders) != 1 {
+		t.Fatalf("Holders() length = %d, want 1", len(holders))
+	}
+	if holders[0].Stack != "" {
+		t.Fatalf("HolderInfo.Stack = %q, want empty when captureStack is false", holders[0].Stack)
+	}
+}
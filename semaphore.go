@@ -1,18 +1,43 @@
 package semaphore
 
 import (
+	"container/list"
+	"context"
 	"errors"
 	"sync"
 	"time"
 )
 
+// waiter is a pending request for n permits. ready is closed once the permits
+// have been granted.
+type waiter struct {
+	n     int
+	ready chan struct{}
+}
+
 // Semaphore is an implementation of semaphore.
+//
+// A caller may request any weight n from 1 up to permits via AcquireMany; the
+// n permits are granted together from a counter rather than taken one channel
+// slot at a time, and a request for more than permits returns an error.
+// Waiters are served in FIFO order so that a large request is never starved
+// by a stream of smaller ones. Every change to the permit count is made under
+// a single mutex (a blocked acquire releases it while it waits), so
+// AvailablePermits, Acquire, Release and DrainPermits are linearizable with
+// respect to one another.
 type Semaphore struct {
+	mutex   sync.Mutex
 	permits int
 	avail   int
-	channel chan struct{}
-	aMutex  *sync.RWMutex
-	rMutex  *sync.Mutex
+	waiters list.List // of *waiter
+
+	// debug holds the optional holder-tracking/leak-detection state. It is
+	// nil until EnableDebug is called. See holders.go.
+	debug *debugState
+
+	// nextToken mints the per-acquisition tokens used to key debug's holder
+	// map, so that acquisitions sharing a caller-supplied id never collide.
+	nextToken int64
 }
 
 // New creates a new Semaphore with specified number of permits.
@@ -21,99 +46,167 @@ func New(permits int) *Semaphore {
 		panic("Invalid number of permits. Less than 1")
 	}
 	return &Semaphore{
-		permits,
-		permits,
-		make(chan struct{}, permits),
-		&sync.RWMutex{},
-		&sync.Mutex{},
+		permits: permits,
+		avail:   permits,
 	}
 }
 
 // Acquire acquires one permit. If it is not available, the goroutine will block until it is available.
 func (s *Semaphore) Acquire() {
-	s.aMutex.Lock()
-	defer s.aMutex.Unlock()
-
-	s.channel <- struct{}{}
-	s.avail--
+	s.acquire(context.Background(), 1)
 }
 
 // AcquireMany is similar to Acquire() but for many permits.
 // An error is returned if n is greater number of permits in the semaphore.
 func (s *Semaphore) AcquireMany(n int) error {
+	return s.acquire(context.Background(), n)
+}
+
+// AcquireWithin is similar to AcquireMany() but cancels if duration elapses before getting the permits.
+// Returns true if successful and false if timeout occurs.
+func (s *Semaphore) AcquireWithin(n int, d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.AcquireManyContext(ctx, n) == nil
+}
+
+// AcquireContext is similar to Acquire() but returns ctx.Err() if ctx is done before a permit becomes available.
+func (s *Semaphore) AcquireContext(ctx context.Context) error {
+	return s.acquire(ctx, 1)
+}
+
+// AcquireManyContext is similar to AcquireMany() but returns ctx.Err() if ctx is done before the permits
+// become available. It starts no goroutine, and a waiter whose ctx is done early is removed from the queue.
+func (s *Semaphore) AcquireManyContext(ctx context.Context, n int) error {
+	return s.acquire(ctx, n)
+}
+
+// TryAcquire attempts to acquire one permit without blocking. It returns
+// false immediately if the permit is not available.
+func (s *Semaphore) TryAcquire() bool {
+	return s.TryAcquireMany(1)
+}
+
+// TryAcquireMany attempts to acquire n permits without blocking. It returns
+// false immediately if the permits are not available, rather than queueing
+// behind other waiters.
+func (s *Semaphore) TryAcquireMany(n int) bool {
+	if n < 1 || n > s.permits {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.avail < n || s.waiters.Len() > 0 {
+		return false
+	}
+	s.avail -= n
+	return true
+}
+
+// acquire is the shared implementation behind every Acquire* variant. It
+// either grants n permits immediately, or enqueues a FIFO waiter and blocks
+// until it is granted or ctx is done.
+func (s *Semaphore) acquire(ctx context.Context, n int) error {
+	if n < 1 {
+		return errors.New("Invalid number of permits. Less than 1")
+	}
 	if n > s.permits {
 		return errors.New("Too many requested permits")
 	}
-	s.aMutex.Lock()
-	defer s.aMutex.Unlock()
 
-	s.avail -= n
-	for ; n > 0; n-- {
-		s.channel <- struct{}{}
+	s.mutex.Lock()
+	if s.avail >= n && s.waiters.Len() == 0 {
+		s.avail -= n
+		s.mutex.Unlock()
+		return nil
 	}
-	s.avail += n
-	return nil
-}
+	w := &waiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mutex.Unlock()
 
-// AcquireWithin is similar to AcquireMany() but cancels if duration elapses before getting the permits.
-// Returns true if successful and false if timeout occurs.
-func (s *Semaphore) AcquireWithin(n int, d time.Duration) bool {
-	timeout := make(chan bool, 1)
-	cancel := make(chan bool, 1)
-	go func() {
-		time.Sleep(d)
-		timeout <- true
-	}()
-	go func() {
-		s.AcquireMany(n)
-		timeout <- false
-		if <-cancel {
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mutex.Lock()
+		select {
+		case <-w.ready:
+			// Already granted by a concurrent Release; hand the permits back.
+			s.mutex.Unlock()
 			s.ReleaseMany(n)
+		default:
+			s.waiters.Remove(elem)
+			// A removed waiter may have been blocking smaller waiters behind
+			// it in the FIFO order; re-run the wake pass so they aren't left
+			// stuck until some unrelated future Release.
+			s.wakeWaiters()
+			s.mutex.Unlock()
 		}
-	}()
-	if <-timeout {
-		cancel <- true
-		return false
+		return ctx.Err()
 	}
-	cancel <- false
-	return true
 }
 
 // Release releases one permit.
 func (s *Semaphore) Release() {
-	s.rMutex.Lock()
-	defer s.rMutex.Unlock()
-
-	<-s.channel
-	s.avail++
+	s.ReleaseMany(1)
 }
 
 // ReleaseMany releases n permits.
 func (s *Semaphore) ReleaseMany(n int) {
+	if n < 1 {
+		panic("Invalid number of permits. Less than 1")
+	}
 	if n > s.permits {
 		panic("Too many requested releases")
 	}
-	for ; n > 0; n-- {
-		s.Release()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.avail+n > s.permits {
+		panic("Too many requested releases")
+	}
+	s.avail += n
+	s.wakeWaiters()
+}
+
+// wakeWaiters grants permits to queued waiters from the front of the list,
+// stopping at the first waiter that still can't be satisfied so that a large
+// request is never skipped over in favour of smaller ones behind it.
+func (s *Semaphore) wakeWaiters() {
+	for e := s.waiters.Front(); e != nil; {
+		w := e.Value.(*waiter)
+		if w.n > s.avail {
+			break
+		}
+		s.avail -= w.n
+		next := e.Next()
+		s.waiters.Remove(e)
+		close(w.ready)
+		e = next
 	}
 }
 
 // AvailablePermits gives number of available unacquired permits.
 func (s *Semaphore) AvailablePermits() int {
-	s.aMutex.RLock()
-	defer s.aMutex.RUnlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
 	if s.avail < 0 {
 		return 0
 	}
+	if s.avail > s.permits {
+		return s.permits
+	}
 	return s.avail
 }
 
 // DrainPermits acquires all available permits and return the number of permits acquired.
 func (s *Semaphore) DrainPermits() int {
-	n := s.AvailablePermits()
-	if n > 0 {
-		s.AcquireMany(n)
-	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n := s.avail
+	s.avail = 0
 	return n
 }
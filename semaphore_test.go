@@ -0,0 +1,128 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireMany_RejectsNonPositiveN(t *testing.T) {
+	s := New(5)
+	if err := s.AcquireMany(-3); err == nil {
+		t.Fatal("AcquireMany(-3): expected error, got nil")
+	}
+	if err := s.AcquireMany(0); err == nil {
+		t.Fatal("AcquireMany(0): expected error, got nil")
+	}
+	if got := s.AvailablePermits(); got != 5 {
+		t.Fatalf("AvailablePermits() = %d, want 5 (rejected calls must not touch avail)", got)
+	}
+}
+
+func TestReleaseMany_RejectsNonPositiveAndOverRelease(t *testing.T) {
+	s := New(5)
+
+	panicked := func(f func()) (didPanic bool) {
+		defer func() {
+			if recover() != nil {
+				didPanic = true
+			}
+		}()
+		f()
+		return false
+	}
+
+	if !panicked(func() { s.ReleaseMany(-2) }) {
+		t.Fatal("ReleaseMany(-2): expected panic")
+	}
+	if !panicked(func() { s.ReleaseMany(0) }) {
+		t.Fatal("ReleaseMany(0): expected panic")
+	}
+	if got := s.AvailablePermits(); got != 5 {
+		t.Fatalf("AvailablePermits() = %d, want 5 (rejected calls must not touch avail)", got)
+	}
+
+	// One extra Release() without a matching Acquire() must not be able to
+	// inflate avail past permits.
+	if !panicked(func() { s.Release() }) {
+		t.Fatal("Release() on a full semaphore: expected panic")
+	}
+	if got := s.AvailablePermits(); got != 5 {
+		t.Fatalf("AvailablePermits() = %d, want 5 after rejected over-release", got)
+	}
+}
+
+func TestAvailablePermits_ClampsToValidRange(t *testing.T) {
+	s := New(5)
+
+	s.mutex.Lock()
+	s.avail = -3
+	s.mutex.Unlock()
+	if got := s.AvailablePermits(); got != 0 {
+		t.Fatalf("AvailablePermits() = %d, want 0 for a corrupted negative avail", got)
+	}
+
+	s.mutex.Lock()
+	s.avail = 9
+	s.mutex.Unlock()
+	if got := s.AvailablePermits(); got != 5 {
+		t.Fatalf("AvailablePermits() = %d, want 5 (clamped to permits) for a corrupted over-permits avail", got)
+	}
+}
+
+func TestDrainPermits(t *testing.T) {
+	s := New(5)
+
+	if n := s.DrainPermits(); n != 5 {
+		t.Fatalf("DrainPermits() = %d, want 5", n)
+	}
+	if got := s.AvailablePermits(); got != 0 {
+		t.Fatalf("AvailablePermits() after drain = %d, want 0", got)
+	}
+	if n := s.DrainPermits(); n != 0 {
+		t.Fatalf("DrainPermits() on an already-drained semaphore = %d, want 0", n)
+	}
+
+	s.Release()
+	if n := s.DrainPermits(); n != 1 {
+		t.Fatalf("DrainPermits() after one Release = %d, want 1", n)
+	}
+}
+
+// A canceled waiter at the head of the FIFO queue must not block smaller
+// waiters behind it from being woken once enough permits are available.
+func TestAcquireManyContext_CancelWakesLaterWaiters(t *testing.T) {
+	s := New(10)
+	if err := s.AcquireMany(10); err != nil {
+		t.Fatalf("AcquireMany(10): %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	errB := make(chan error, 1)
+	go func() { errB <- s.AcquireManyContext(ctx, 8) }()
+
+	// Give B a chance to enqueue ahead of C.
+	time.Sleep(5 * time.Millisecond)
+
+	doneC := make(chan error, 1)
+	go func() { doneC <- s.AcquireManyContext(context.Background(), 2) }()
+
+	// Give C a chance to enqueue behind B.
+	time.Sleep(5 * time.Millisecond)
+
+	s.ReleaseMany(5)
+
+	if err := <-errB; err != context.DeadlineExceeded {
+		t.Fatalf("waiter B: got %v, want context.DeadlineExceeded", err)
+	}
+
+	select {
+	case err := <-doneC:
+		if err != nil {
+			t.Fatalf("waiter C: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter C never woke after B's context expired")
+	}
+}
@@ -0,0 +1,49 @@
+package semaphore
+
+import "testing"
+
+func TestTryAcquire_ExhaustsThenFails(t *testing.T) {
+	s := New(2)
+
+	if !s.TryAcquire() {
+		t.Fatal("TryAcquire #1: expected true")
+	}
+	if !s.TryAcquire() {
+		t.Fatal("TryAcquire #2: expected true")
+	}
+	if s.TryAcquire() {
+		t.Fatal("TryAcquire #3: expected false, permits are exhausted")
+	}
+
+	s.Release()
+	if !s.TryAcquire() {
+		t.Fatal("TryAcquire after Release: expected true")
+	}
+}
+
+func TestTryAcquireMany_FailsWithoutBlocking(t *testing.T) {
+	s := New(4)
+
+	if !s.TryAcquireMany(4) {
+		t.Fatal("TryAcquireMany(4): expected true")
+	}
+	if s.TryAcquireMany(1) {
+		t.Fatal("TryAcquireMany(1) on exhausted semaphore: expected false")
+	}
+	if got := s.AvailablePermits(); got != 0 {
+		t.Fatalf("AvailablePermits() = %d, want 0", got)
+	}
+}
+
+func TestTryAcquireMany_RejectsNonPositiveN(t *testing.T) {
+	s := New(4)
+	if s.TryAcquireMany(0) {
+		t.Fatal("TryAcquireMany(0): expected false")
+	}
+	if s.TryAcquireMany(-1) {
+		t.Fatal("TryAcquireMany(-1): expected false")
+	}
+	if got := s.AvailablePermits(); got != 4 {
+		t.Fatalf("AvailablePermits() = %d, want 4 (rejected calls must not touch avail)", got)
+	}
+}